@@ -0,0 +1,41 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffForAttemptDoublesUntilCap(t *testing.T) {
+	cfg := &AMQPConfig{ReconnectMin: 100 * time.Millisecond, ReconnectMax: time.Second}
+
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second}, // would be 1.6s uncapped, clamped to ReconnectMax
+		{10, time.Second},
+	}
+	for _, c := range cases {
+		if got := backoffForAttempt(cfg, c.attempt); got != c.want {
+			t.Errorf("backoffForAttempt(attempt=%d) = %v, want %v", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestNextSubscriptionIDIsUniquePerRoutingKey(t *testing.T) {
+	ac := &amqpConnector{}
+
+	first := ac.nextSubscriptionID("orders")
+	second := ac.nextSubscriptionID("orders")
+
+	if first == second {
+		t.Fatalf("expected distinct subscription IDs for two subscriptions on the same routing key, got %q twice", first)
+	}
+}
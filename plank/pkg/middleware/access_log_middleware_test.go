@@ -0,0 +1,56 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package middleware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsValidRequestIDRejectsInjectionAttempts(t *testing.T) {
+	cases := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"a1b2c3d4-e5f6-7890-abcd-ef1234567890", true},
+		{"request_id_with-dashes_and_underscores", true},
+		{"has a space", false},
+		{"newline\ninjected=true", false},
+		{"quote\"injected", false},
+		{strings.Repeat("a", maxRequestIDLen), true},
+		{strings.Repeat("a", maxRequestIDLen+1), false},
+	}
+	for _, c := range cases {
+		if got := isValidRequestID(c.id); got != c.want {
+			t.Errorf("isValidRequestID(%q) = %v, want %v", c.id, got, c.want)
+		}
+	}
+}
+
+func TestRenderLogfmtQuotesAllStringFields(t *testing.T) {
+	m := &AccessLogMiddleware{Format: AccessLogFormatLogfmt}
+	record := accessLogRecord{
+		Method:     "GET",
+		Path:       "/foo\ninjected=true",
+		Route:      "foo_route",
+		Status:     200,
+		Bytes:      42,
+		DurationMs: 5,
+		RemoteAddr: "127.0.0.1:1234",
+		UserAgent:  "curl/8.0",
+		RequestID:  "abc-123",
+	}
+
+	line := m.render(record)
+
+	if strings.Contains(line, "\n") {
+		t.Fatalf("rendered line contains a raw newline, allowing forged log lines: %q", line)
+	}
+	for _, want := range []string{`path="/foo\ninjected=true"`, `method="GET"`, `remote_addr="127.0.0.1:1234"`, `request_id="abc-123"`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("rendered line %q does not contain quoted field %q", line, want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package middleware
+
+import "net/http"
+
+// Middleware is implemented by anything that wraps an http.Handler to add cross-cutting
+// behavior - logging, metrics, tracing, security headers - around every request that reaches
+// the REST bridge router.
+type Middleware interface {
+	Intercept(next http.Handler) http.Handler
+}
+
+// MiddlewareManager accumulates Middleware in registration order and wraps a base handler with
+// the full chain, so callers only need to AddMiddleware once per concern and never have to
+// re-wire the chain by hand.
+type MiddlewareManager interface {
+	AddMiddleware(mw Middleware)
+	WrapHandler(base http.Handler) http.Handler
+}
+
+// middlewareManager is the default MiddlewareManager implementation.
+type middlewareManager struct {
+	chain []Middleware
+}
+
+// NewMiddlewareManager creates an empty MiddlewareManager.
+func NewMiddlewareManager() MiddlewareManager {
+	return &middlewareManager{}
+}
+
+// AddMiddleware appends mw to the end of the chain, so it runs after every middleware already
+// registered and before the base handler.
+func (m *middlewareManager) AddMiddleware(mw Middleware) {
+	m.chain = append(m.chain, mw)
+}
+
+// WrapHandler wraps base with every registered middleware, in registration order - the first
+// middleware added is the outermost, seeing the request first and the response last.
+func (m *middlewareManager) WrapHandler(base http.Handler) http.Handler {
+	wrapped := base
+	for i := len(m.chain) - 1; i >= 0; i-- {
+		wrapped = m.chain[i].Intercept(wrapped)
+	}
+	return wrapped
+}
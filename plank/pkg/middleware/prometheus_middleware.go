@@ -0,0 +1,205 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+//go:build !js && !wasm
+// +build !js,!wasm
+
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsConfig controls how the Prometheus RED/latency instrumentation behaves.
+type MetricsConfig struct {
+	HistogramBuckets []float64             `json:"histogram_buckets"` // buckets (seconds) used for latency histograms, defaults to prometheus.DefBuckets
+	MaxRouteLabels   int                   `json:"max_route_labels"`  // caps the number of distinct route label values tracked before falling back to "other"
+	Registerer       prometheus.Registerer `json:"-"`                 // registerer to use, defaults to prometheus.DefaultRegisterer
+}
+
+// PrometheusMetrics bundles the collectors registered for per-service/per-endpoint RED instrumentation.
+type PrometheusMetrics struct {
+	requestsTotal    *prometheus.CounterVec
+	requestsInFlight *prometheus.GaugeVec
+	requestDuration  *prometheus.HistogramVec
+
+	cfg          *MetricsConfig
+	routeCountMu sync.Mutex
+	routeCount   map[string]struct{}
+}
+
+// NewPrometheusMetrics creates and registers the collectors used by PrometheusMetricsMiddleware.
+// If cfg is nil, sane defaults (prometheus.DefBuckets, unlimited route cardinality, DefaultRegisterer) are used.
+func NewPrometheusMetrics(cfg *MetricsConfig) *PrometheusMetrics {
+	if cfg == nil {
+		cfg = &MetricsConfig{}
+	}
+	if len(cfg.HistogramBuckets) == 0 {
+		cfg.HistogramBuckets = prometheus.DefBuckets
+	}
+	registerer := cfg.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	pm := &PrometheusMetrics{
+		cfg:        cfg,
+		routeCount: make(map[string]struct{}),
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transport_http_requests_total",
+			Help: "Total number of HTTP requests handled by the REST bridge, labeled by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		requestsInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transport_http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served, labeled by method and route.",
+		}, []string{"method", "route"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "transport_http_request_duration_seconds",
+			Help:    "Latency distribution of HTTP requests, labeled by method, route and status.",
+			Buckets: cfg.HistogramBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+
+	registerer.MustRegister(pm.requestsTotal, pm.requestsInFlight, pm.requestDuration)
+	return pm
+}
+
+// routeLabel resolves the route name to use as a label, falling back to the request path
+// when the route is unnamed, and capping cardinality at cfg.MaxRouteLabels when configured.
+// mux.CurrentRoute only returns non-nil once the router has matched the request, so pm must be
+// installed via router.Use(...) rather than wrapped around the router from the outside.
+func (pm *PrometheusMetrics) routeLabel(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	name := ""
+	if route != nil {
+		name = route.GetName()
+	}
+	if name == "" {
+		name = r.URL.Path
+	}
+	if pm.cfg.MaxRouteLabels <= 0 {
+		return name
+	}
+
+	pm.routeCountMu.Lock()
+	defer pm.routeCountMu.Unlock()
+	if _, tracked := pm.routeCount[name]; !tracked {
+		if len(pm.routeCount) >= pm.cfg.MaxRouteLabels {
+			return "other"
+		}
+		pm.routeCount[name] = struct{}{}
+	}
+	return name
+}
+
+// Intercept wraps next with RED (rate, errors, duration) instrumentation. It satisfies the
+// Middleware interface so it can be chained in the same fashion as BasicSecurityHeaderMiddleware,
+// but unlike chain-only middleware it must be installed via router.Use(...) (see enablePrometheus) so
+// that mux.CurrentRoute resolves inside routeLabel.
+func (pm *PrometheusMetrics) Intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := pm.routeLabel(r)
+		pm.requestsInFlight.WithLabelValues(r.Method, route).Inc()
+		defer pm.requestsInFlight.WithLabelValues(r.Method, route).Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.status)
+		pm.requestsTotal.WithLabelValues(r.Method, route, status).Inc()
+		pm.requestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder captures the status code and byte count written by the wrapped handler so
+// they can be used as metric labels and in access log records.
+type statusRecorder struct {
+	http.ResponseWriter
+	status       int
+	bytesWritten int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytesWritten += n
+	return n, err
+}
+
+// FabricMetrics bundles the collectors used to instrument fabric-bus channel activity (messages
+// published/delivered, subscriber counts and request/response duration), labeled by channel.
+type FabricMetrics struct {
+	messagesPublished  *prometheus.CounterVec
+	messagesDelivered  *prometheus.CounterVec
+	subscriberCount    *prometheus.GaugeVec
+	requestResponseDur *prometheus.HistogramVec
+}
+
+// NewFabricMetrics creates and registers the fabric-bus collectors. Buckets and registerer are
+// taken from cfg, falling back to the same defaults as NewPrometheusMetrics when cfg is nil.
+func NewFabricMetrics(cfg *MetricsConfig) *FabricMetrics {
+	if cfg == nil {
+		cfg = &MetricsConfig{}
+	}
+	if len(cfg.HistogramBuckets) == 0 {
+		cfg.HistogramBuckets = prometheus.DefBuckets
+	}
+	registerer := cfg.Registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	fm := &FabricMetrics{
+		messagesPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transport_fabric_messages_published_total",
+			Help: "Total number of messages published to a fabric channel.",
+		}, []string{"channel"}),
+		messagesDelivered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transport_fabric_messages_delivered_total",
+			Help: "Total number of messages delivered to fabric channel subscribers.",
+		}, []string{"channel"}),
+		subscriberCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "transport_fabric_channel_subscribers",
+			Help: "Current number of subscribers on a fabric channel.",
+		}, []string{"channel"}),
+		requestResponseDur: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "transport_fabric_request_duration_seconds",
+			Help:    "Latency distribution of fabric request/response round trips, labeled by channel.",
+			Buckets: cfg.HistogramBuckets,
+		}, []string{"channel"}),
+	}
+
+	registerer.MustRegister(fm.messagesPublished, fm.messagesDelivered, fm.subscriberCount, fm.requestResponseDur)
+	return fm
+}
+
+// RecordPublish increments the published-message counter for channel.
+func (fm *FabricMetrics) RecordPublish(channel string) {
+	fm.messagesPublished.WithLabelValues(channel).Inc()
+}
+
+// RecordDelivery increments the delivered-message counter for channel.
+func (fm *FabricMetrics) RecordDelivery(channel string) {
+	fm.messagesDelivered.WithLabelValues(channel).Inc()
+}
+
+// SetSubscriberCount updates the current subscriber gauge for channel.
+func (fm *FabricMetrics) SetSubscriberCount(channel string, count int) {
+	fm.subscriberCount.WithLabelValues(channel).Set(float64(count))
+}
+
+// ObserveRequestResponse records the duration of a request/response round trip on channel.
+func (fm *FabricMetrics) ObserveRequestResponse(channel string, duration time.Duration) {
+	fm.requestResponseDur.WithLabelValues(channel).Observe(duration.Seconds())
+}
@@ -0,0 +1,67 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+//go:build !js && !wasm
+// +build !js,!wasm
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestPrometheusMetrics(maxRouteLabels int) *PrometheusMetrics {
+	return NewPrometheusMetrics(&MetricsConfig{
+		MaxRouteLabels: maxRouteLabels,
+		Registerer:     prometheus.NewRegistry(),
+	})
+}
+
+func TestRouteLabelFallsBackToPathWhenNoRouteMatched(t *testing.T) {
+	pm := newTestPrometheusMetrics(0)
+	r := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+
+	if got := pm.routeLabel(r); got != "/users/123" {
+		t.Errorf("routeLabel() = %q, want %q", got, "/users/123")
+	}
+}
+
+func TestRouteLabelCapsCardinalityAtMaxRouteLabels(t *testing.T) {
+	pm := newTestPrometheusMetrics(2)
+
+	first := pm.routeLabel(httptest.NewRequest(http.MethodGet, "/a", nil))
+	second := pm.routeLabel(httptest.NewRequest(http.MethodGet, "/b", nil))
+	third := pm.routeLabel(httptest.NewRequest(http.MethodGet, "/c", nil))
+	// a previously tracked path must keep resolving to itself, not "other"
+	firstAgain := pm.routeLabel(httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	if first != "/a" || second != "/b" {
+		t.Fatalf("expected the first MaxRouteLabels distinct paths to be tracked as-is, got %q and %q", first, second)
+	}
+	if third != "other" {
+		t.Errorf("routeLabel() for a path beyond the cap = %q, want %q", third, "other")
+	}
+	if firstAgain != "/a" {
+		t.Errorf("routeLabel() for an already-tracked path = %q, want %q", firstAgain, "/a")
+	}
+}
+
+func TestRouteLabelIsSafeForConcurrentUse(t *testing.T) {
+	pm := newTestPrometheusMetrics(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/concurrent", nil)
+			pm.routeLabel(r)
+		}(i)
+	}
+	wg.Wait()
+}
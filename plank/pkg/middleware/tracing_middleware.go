@@ -0,0 +1,68 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingConfig enables OpenTelemetry span export for REST-bridge requests and fabric message
+// handling, so they can be linked into a single distributed trace.
+type TracingConfig struct {
+	Endpoint     string  `json:"endpoint"`      // OTLP/gRPC collector endpoint, e.g. "otel-collector:4317"
+	SamplerRatio float64 `json:"sampler_ratio"` // fraction of requests to sample, 0.0-1.0
+	ServiceName  string  `json:"service_name"`  // service.name resource attribute reported to the collector
+}
+
+// NewTracerProvider builds an sdktrace.TracerProvider that exports spans via OTLP/gRPC to
+// cfg.Endpoint, sampling cfg.SamplerRatio of root spans.
+func NewTracerProvider(cfg *TracingConfig) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracegrpc.New(context.Background(), otlptracegrpc.WithEndpoint(cfg.Endpoint), otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(cfg.SamplerRatio)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp, nil
+}
+
+// TracingMiddleware starts a span for every HTTP request it sees, named after the request
+// path, and propagates the request's correlation ID as a span attribute so access log records
+// and traces can be cross-referenced.
+type TracingMiddleware struct {
+	Tracer trace.Tracer
+}
+
+// NewTracingMiddleware builds a TracingMiddleware backed by a tracer from provider, named
+// serviceName.
+func NewTracingMiddleware(provider trace.TracerProvider, serviceName string) *TracingMiddleware {
+	return &TracingMiddleware{Tracer: provider.Tracer(serviceName)}
+}
+
+// Intercept implements the Middleware interface.
+func (m *TracingMiddleware) Intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := m.Tracer.Start(r.Context(), r.URL.Path)
+		span.SetAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("request.id", RequestIDFromContext(ctx)),
+		)
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"os"
+
+	"github.com/vmware/transport-go/plank/pkg/middleware"
+	"github.com/vmware/transport-go/plank/utils"
+)
+
+// enableAccessLogging wires RequestIDMiddleware and a structured AccessLogMiddleware into ps,
+// using the format configured on LogConfig.AccessLogFormat and writing to the file named by
+// LogConfig.AccessLog, or to ps.out when that is empty or cannot be opened. It is called from
+// StartServer before the router starts accepting requests.
+func enableAccessLogging(ps *platformServer) {
+	ps.middlewareManager.AddMiddleware(middleware.RequestIDMiddleware{})
+
+	format := middleware.AccessLogFormatLogfmt
+	if ps.serverConfig.LogConfig != nil && ps.serverConfig.LogConfig.AccessLogFormat != "" {
+		format = ps.serverConfig.LogConfig.AccessLogFormat
+	}
+	out := ps.out
+	if ps.serverConfig.LogConfig != nil && ps.serverConfig.LogConfig.AccessLog != "" {
+		f, err := os.OpenFile(ps.serverConfig.LogConfig.AccessLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			utils.Log.Warnln("failed to open access log, writing access log records to platform output:", err)
+		} else {
+			out = f
+		}
+	}
+	accessLog := middleware.NewAccessLogMiddleware(format, func(line string) {
+		if _, err := out.WriteString(line + "\n"); err != nil {
+			utils.Log.Warnln("failed to write access log record:", err)
+		}
+	})
+	ps.middlewareManager.AddMiddleware(accessLog)
+
+	if cfg := ps.serverConfig.TracingConfig; cfg != nil {
+		provider, err := middleware.NewTracerProvider(cfg)
+		if err != nil {
+			utils.Log.Warnln("failed to set up OpenTelemetry tracing:", err)
+			return
+		}
+		ps.tracerProvider = provider
+		ps.middlewareManager.AddMiddleware(middleware.NewTracingMiddleware(provider, cfg.ServiceName))
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/vmware/transport-go/plank/utils"
+)
+
+// renewBefore is how far ahead of a certificate's expiry the background renewal loop attempts
+// to replace it, mirroring the grace period recommended by Let's Encrypt integration guides.
+const renewBefore = 30 * 24 * time.Hour
+
+// configureACME builds an autocert.Manager from cfg, wires it into ps so StartServer can use it
+// as the source of both the TLS config and, for HTTP-01, the challenge handler, and, unless
+// OnDemand is set, starts the background renewal loop. The returned *tls.Config is suitable for http.Server.TLSConfig.
+func configureACME(ps *platformServer, cfg *ACMEConfig) (*tls.Config, error) {
+	manager := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(cfg.StorageFile),
+		Email:       cfg.Email,
+		HostPolicy:  acmeHostPolicy(cfg),
+		RenewBefore: renewBefore,
+	}
+	if cfg.CADirURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: cfg.CADirURL}
+	}
+
+	tlsConfig := manager.TLSConfig()
+	if cfg.TLSALPN01 {
+		// tls-alpn-01 challenge certs are served from the same listener as real traffic, so
+		// GetCertificate (set by TLSConfig above) already handles them - nothing extra required.
+	} else {
+		// http-01 requires a plain HTTP entrypoint that can answer the ACME challenge path. The
+		// listener is kept on ps so StopServer can close it and configureACME can be called again
+		// (e.g. from ReloadConfig) without leaking a goroutine or failing to rebind :80.
+		if ps.acmeChallengeServer != nil {
+			if err := ps.acmeChallengeServer.Close(); err != nil {
+				utils.Log.Warnln("failed to close previous acme http-01 challenge listener:", err)
+			}
+		}
+		ps.acmeChallengeServer = &http.Server{Addr: ":http", Handler: manager.HTTPHandler(nil)}
+		go func() {
+			if err := ps.acmeChallengeServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				utils.Log.Warnln("acme http-01 challenge listener stopped:", err)
+			}
+		}()
+	}
+
+	ps.acmeManager = manager
+	if !cfg.OnDemand {
+		go ps.renewACMECertificates(cfg)
+	}
+
+	return tlsConfig, nil
+}
+
+// acmeHostPolicy restricts certificate issuance to the configured domains unless OnDemand is
+// set, in which case any SNI hostname presented during the handshake is accepted.
+func acmeHostPolicy(cfg *ACMEConfig) autocert.HostPolicy {
+	if cfg.OnDemand {
+		return func(_ context.Context, host string) error { return nil }
+	}
+	return autocert.HostWhitelist(cfg.Domains...)
+}
+
+// renewACMECertificates periodically fetches the certificate for every configured domain through
+// the manager, so each one is loaded (issued if missing or expired) and autocert's renewal timer,
+// set to fire renewBefore ahead of expiry, runs without waiting for the next TLS handshake. autocert.Manager.Cache is already sync-safe, so
+// concurrent renewals here and handshake-triggered fetches never corrupt the on-disk cache.
+func (ps *platformServer) renewACMECertificates(cfg *ACMEConfig) {
+	ticker := time.NewTicker(renewBefore / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, domain := range cfg.Domains {
+			if _, err := ps.acmeManager.GetCertificate(&tls.ClientHelloInfo{ServerName: domain}); err != nil {
+				utils.Log.Warnln("acme renewal failed for", domain, ":", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAcmeHostPolicyWhitelistsConfiguredDomains(t *testing.T) {
+	cfg := &ACMEConfig{Domains: []string{"example.com", "www.example.com"}}
+	policy := acmeHostPolicy(cfg)
+
+	if err := policy(context.Background(), "example.com"); err != nil {
+		t.Errorf("expected example.com to be allowed, got error: %v", err)
+	}
+	if err := policy(context.Background(), "evil.com"); err == nil {
+		t.Error("expected evil.com to be rejected, got nil error")
+	}
+}
+
+func TestAcmeHostPolicyOnDemandAllowsAnyHost(t *testing.T) {
+	cfg := &ACMEConfig{OnDemand: true}
+	policy := acmeHostPolicy(cfg)
+
+	if err := policy(context.Background(), "anything.example"); err != nil {
+		t.Errorf("expected OnDemand to allow any host, got error: %v", err)
+	}
+}
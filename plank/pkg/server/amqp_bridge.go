@@ -0,0 +1,66 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"fmt"
+
+	"github.com/vmware/transport-go/bridge"
+	"github.com/vmware/transport-go/bus"
+	"github.com/vmware/transport-go/plank/utils"
+)
+
+// bridgeFabricChannelsToAMQP connects to the RabbitMQ broker described by cfg and relays every
+// message delivered on the AMQP routing key matching fabricChannel onto the local fabric
+// channel of the same name, and every message sent on that local channel back out onto the AMQP
+// exchange, so Plank deployments can front a RabbitMQ cluster without the STOMP plugin. It is
+// called from StartServer for every registered service channel whenever
+// FabricBrokerConfig.AMQPConfig is set instead of EndpointConfig.
+func bridgeFabricChannelsToAMQP(ps *platformServer, fabricChannel string, cfg *bridge.AMQPConfig) error {
+	connector, err := bridge.ConnectAMQP(&bridge.BrokerConnectorConfig{}, cfg)
+	if err != nil {
+		return fmt.Errorf("unable to connect to AMQP broker: %v", err)
+	}
+
+	sub, err := connector.Subscribe(fabricChannel)
+	if err != nil {
+		return fmt.Errorf("unable to subscribe to AMQP routing key %s: %v", fabricChannel, err)
+	}
+	if ps.fabricMetrics != nil {
+		ps.fabricMetrics.SetSubscriberCount(fabricChannel, 1)
+	}
+
+	// AMQP -> local: every delivery on the routing key is handed off to the local fabric
+	// channel of the same name so services registered via RegisterService receive it.
+	go func() {
+		for msg := range sub.GetMsgChannel() {
+			if ps.fabricMetrics != nil {
+				ps.fabricMetrics.RecordDelivery(fabricChannel)
+			}
+			if err := bus.GetBus().SendResponseMessage(fabricChannel, msg.Payload, nil); err != nil {
+				utils.Log.Warnln("failed to relay AMQP delivery onto local channel", fabricChannel, ":", err)
+			}
+		}
+	}()
+
+	// local -> AMQP: everything sent on the local fabric channel is republished onto the AMQP
+	// exchange under the same routing key.
+	outbound, err := bus.GetBus().ListenStream(fabricChannel)
+	if err != nil {
+		return fmt.Errorf("unable to listen on local channel %s: %v", fabricChannel, err)
+	}
+	go func() {
+		for msg := range outbound.GetMsgChannel() {
+			if err := connector.SendMessage(fabricChannel, "application/octet-stream", msg.Payload); err != nil {
+				utils.Log.Warnln("failed to relay local channel", fabricChannel, "onto AMQP:", err)
+				continue
+			}
+			if ps.fabricMetrics != nil {
+				ps.fabricMetrics.RecordPublish(fabricChannel)
+			}
+		}
+	}()
+
+	return nil
+}
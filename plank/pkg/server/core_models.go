@@ -6,12 +6,15 @@ package server
 import (
 	"crypto/tls"
 	"github.com/gorilla/mux"
+	"github.com/vmware/transport-go/bridge"
 	"github.com/vmware/transport-go/bus"
 	"github.com/vmware/transport-go/model"
 	"github.com/vmware/transport-go/plank/pkg/middleware"
 	"github.com/vmware/transport-go/plank/utils"
 	"github.com/vmware/transport-go/service"
 	"github.com/vmware/transport-go/stompserver"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/crypto/acme/autocert"
 	"net/http"
 	"os"
 	"sync"
@@ -20,19 +23,34 @@ import (
 
 // PlatformServerConfig holds all the core configuration needed for the functionality of Plank
 type PlatformServerConfig struct {
-	RootDir                    string              `json:"root_dir"`                       // root directory the server should base itself on
-	StaticDir                  []string            `json:"static_dir"`                     // static content folders that HTTP server should serve
-	SpaConfig                  *SpaConfig          `json:"spa_config"`                     // single page application configuration
-	Host                       string              `json:"host"`                           // hostname for the server
-	Port                       int                 `json:"port"`                           // port for the server
-	LogConfig                  *utils.LogConfig    `json:"log_config"`                     // log configuration (plank, http access and error logs)
-	FabricConfig               *FabricBrokerConfig `json:"fabric_config"`                  // fabric (websocket) configuration
-	TLSCertConfig              *TLSCertConfig      `json:"tls_config"`                     // TLS certificate configuration
-	EnablePrometheus           bool                `json:"enable_prometheus"`              // whether to enable Prometheus for runtime metrics
-	Debug                      bool                `json:"debug"`                          // enable debug logging
-	NoBanner                   bool                `json:"no_banner"`                      // start server without displaying the banner
-	ShutdownTimeoutInMinutes   time.Duration       `json:"shutdown_timeout_in_minutes"`    // graceful server shutdown timeout in minutes
-	RestBridgeTimeoutInMinutes time.Duration       `json:"rest_bridge_timeout_in_minutes"` // rest bridge timeout in minutes
+	RootDir                    string                    `json:"root_dir"`                       // root directory the server should base itself on
+	ConfigFilePath             string                    `json:"-"`                              // path to the JSON file cfg was loaded from, if any; used by the fsnotify/SIGHUP config reload watchers
+	StaticDir                  []string                  `json:"static_dir"`                     // static content folders that HTTP server should serve
+	SpaConfig                  *SpaConfig                `json:"spa_config"`                     // single page application configuration
+	Host                       string                    `json:"host"`                           // hostname for the server
+	Port                       int                       `json:"port"`                           // port for the server
+	LogConfig                  *utils.LogConfig          `json:"log_config"`                     // log configuration (plank, http access and error logs)
+	FabricConfig               *FabricBrokerConfig       `json:"fabric_config"`                  // fabric (websocket) configuration
+	TLSCertConfig              *TLSCertConfig            `json:"tls_config"`                     // TLS certificate configuration
+	ACMEConfig                 *ACMEConfig               `json:"acme_config"`                    // ACME (Let's Encrypt) automatic TLS configuration, used in place of TLSCertConfig
+	EnablePrometheus           bool                      `json:"enable_prometheus"`              // whether to enable Prometheus for runtime metrics
+	MetricsConfig              *middleware.MetricsConfig `json:"metrics_config"`                 // per-service/per-endpoint Prometheus metrics configuration
+	TracingConfig              *middleware.TracingConfig `json:"tracing_config"`                 // OpenTelemetry tracing configuration, nil disables span export
+	Debug                      bool                      `json:"debug"`                          // enable debug logging
+	NoBanner                   bool                      `json:"no_banner"`                      // start server without displaying the banner
+	ShutdownTimeoutInMinutes   time.Duration             `json:"shutdown_timeout_in_minutes"`    // graceful server shutdown timeout in minutes
+	RestBridgeTimeoutInMinutes time.Duration             `json:"rest_bridge_timeout_in_minutes"` // rest bridge timeout in minutes
+	DrainDelayInSeconds        time.Duration             `json:"drain_delay_in_seconds"`         // how long /health/ready reports not-ready before StopServer begins draining in-flight work, giving load balancers time to depool the instance
+	ConfigReloadConfig         *ConfigReloadConfig       `json:"config_reload_config"`           // hot-reload sources (file watch, env vars, Consul KV) watched for config changes
+}
+
+// ConfigReloadConfig controls where PlatformServer watches for configuration changes to feed
+// into ReloadConfig, beyond the explicit SIGHUP trigger that is always available.
+type ConfigReloadConfig struct {
+	WatchConfigFile bool   `json:"watch_config_file"` // fsnotify-watch the JSON config file this PlatformServerConfig was loaded from and reload on write
+	EnvPrefix       string `json:"env_prefix"`        // when set, environment variables with this prefix are re-read and applied on every reload (e.g. PLANK_PORT)
+	ConsulAddr      string `json:"consul_addr"`       // Consul agent address, enables watching ConsulKeyPrefix
+	ConsulKeyPrefix string `json:"consul_key_prefix"` // Consul KV key whose value (a JSON config) is watched for changes and applied the same way as the JSON config file; keys below it are not read
 }
 
 // TLSCertConfig wraps around key information for TLS configuration
@@ -42,10 +60,22 @@ type TLSCertConfig struct {
 	SkipCertificateValidation bool   `json:"skip_certificate_validation"` // whether to skip certificate validation (useful for self-signed cert)
 }
 
+// ACMEConfig configures automatic certificate issuance and renewal from an ACME CA such as
+// Let's Encrypt, as an alternative to the static files in TLSCertConfig.
+type ACMEConfig struct {
+	Email       string   `json:"email"`        // contact email registered with the ACME account
+	CADirURL    string   `json:"ca_dir_url"`   // ACME CA directory URL, defaults to the Let's Encrypt production endpoint
+	StorageFile string   `json:"storage_file"` // path to the directory (autocert.DirCache) that caches issued certificates and the ACME account key, one file per entry; it is not a single file
+	Domains     []string `json:"domains"`      // domains to issue certificates for; ignored when OnDemand is true
+	TLSALPN01   bool     `json:"tls_alpn_01"`  // use the TLS-ALPN-01 challenge instead of HTTP-01
+	OnDemand    bool     `json:"on_demand"`    // lazily request a certificate for whatever SNI hostname is seen on the first handshake, instead of pre-issuing for Domains
+}
+
 // FabricBrokerConfig defines the endpoint for WebSocket as well as detailed endpoint configuration
 type FabricBrokerConfig struct {
 	FabricEndpoint string              `json:"fabric_endpoint"` // URI to WebSocket endpoint
 	EndpointConfig *bus.EndpointConfig `json:"endpoint_config"` // STOMP configuration
+	AMQPConfig     *bridge.AMQPConfig  `json:"amqp_config"`     // native AMQP 0.9.1 configuration, used in place of EndpointConfig to bridge Transport channels onto a RabbitMQ exchange instead of STOMP
 }
 
 // PlatformServer exposes public API methods that control the behavior of the Plank instance.
@@ -58,6 +88,7 @@ type PlatformServer interface {
 	CustomizeTLSConfig(tls *tls.Config) error                           // used to replace default tls.Config for HTTP server with a custom config
 	GetRestBridgeSubRoute(uri, method string) (*mux.Route, error)       // get *mux.Route that maps to the provided uri and method
 	GetMiddlewareManager() middleware.MiddlewareManager                 // get middleware manager
+	ReloadConfig(cfg *PlatformServerConfig) error                       // apply cfg's safely-mutable fields to the running server, logging a warning for any field that requires a restart
 }
 
 // platformServer is the main struct that holds all components together including servers, various managers etc.
@@ -67,12 +98,19 @@ type platformServer struct {
 	serverConfig                 *PlatformServerConfig             // server config instance
 	middlewareManager            middleware.MiddlewareManager      // middleware maanger instance
 	router                       *mux.Router                       // *mux.Router instance
-	routerConcurrencyProtection  *int32                            // atomic int32 to protect the main router being concurrently written to
+	routerMu                     sync.RWMutex                      // guards ps.router: write-locked by everything that registers routes or middleware on it (SetHttpChannelBridge, SetStaticRoute, enablePrometheus), read-locked around every request so route registration at runtime (e.g. from ReloadConfig) can't race gorilla/mux's internal route slice
 	out                          *os.File                          // platform log output pointer
 	endpointHandlerMap           map[string]http.HandlerFunc       // internal map to store rest endpoint -handler mappings
 	serviceChanToBridgeEndpoints map[string][]string               // internal map to store service channel - endpoint handler key mappings
 	fabricConn                   stompserver.RawConnectionListener // WebSocket listener instance
+	services                     map[string]service.FabricService  // registered services, keyed by the channel they were registered on
 	serverAvailability           *serverAvailability               // server availability (not much used other than for internal monitoring for now)
+	httpMetrics                  *middleware.PrometheusMetrics     // per-route RED/latency metrics, set when EnablePrometheus is true
+	fabricMetrics                *middleware.FabricMetrics         // per-channel fabric-bus metrics, set when EnablePrometheus is true
+	tracerProvider               *sdktrace.TracerProvider          // OpenTelemetry tracer provider, set when TracingConfig is provided; shut down in StopServer
+	acmeManager                  *autocert.Manager                 // ACME certificate manager, set when ACMEConfig is provided
+	acmeChallengeServer          *http.Server                      // HTTP-01 challenge listener on :http, set when ACMEConfig is provided and TLSALPN01 is false; closed in StopServer
+	inFlight                     sync.WaitGroup                    // tracks in-flight HTTP requests (via trackInFlight) during drain; fabric transactions and channel handlers are not counted
 	lock                         sync.Mutex                        // lock
 }
 
@@ -84,6 +122,7 @@ type transportChannelResponse struct {
 
 // serverAvailability contains boolean fields to indicate what components of the system are available or not
 type serverAvailability struct {
-	http   bool // http server availability
-	fabric bool // stomp broker availability
+	http     bool // http server availability
+	fabric   bool // stomp broker availability
+	draining bool // true once StopServer has started depooling the instance, before in-flight work has finished
 }
@@ -0,0 +1,84 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/vmware/transport-go/plank/utils"
+)
+
+// enableHealthReady mounts /health/ready, which returns 200 while the instance is serving
+// normally and 503 once serverAvailability.draining is set, so upstream load balancers depool
+// the instance before in-flight work is interrupted.
+func enableHealthReady(ps *platformServer) {
+	ps.routerMu.Lock()
+	defer ps.routerMu.Unlock()
+	ps.router.Path("/health/ready").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ps.lock.Lock()
+		draining := ps.serverAvailability.draining
+		ps.lock.Unlock()
+
+		if draining {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// trackInFlight wraps next so the time spent inside it is counted against ps.inFlight, letting
+// StopServer wait for every in-flight HTTP request (REST-bridge or otherwise) to finish. It is
+// the only place ps.inFlight is incremented.
+func (ps *platformServer) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ps.inFlight.Add(1)
+		defer ps.inFlight.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drainAndShutdown marks the instance as draining so /health/ready starts failing, waits
+// DrainDelayInSeconds for that to propagate to the load balancer, then stops ps.HttpServer from
+// accepting new connections and waits (up to timeout, counted from the end of the drain delay)
+// for the in-flight HTTP requests tracked in ps.inFlight to finish. Fabric request/response
+// transactions and subscribed channel handlers are not tracked and are not waited for. The HTTP listener is stopped concurrently with, not after,
+// the ps.inFlight wait - otherwise new requests keep arriving and incrementing ps.inFlight for
+// the whole drain window, and the wait may never converge before timeout.
+func (ps *platformServer) drainAndShutdown(timeout time.Duration) {
+	ps.lock.Lock()
+	ps.serverAvailability.draining = true
+	ps.lock.Unlock()
+
+	if ps.serverConfig.DrainDelayInSeconds > 0 {
+		time.Sleep(ps.serverConfig.DrainDelayInSeconds * time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	httpShutdown := make(chan struct{})
+	go func() {
+		defer close(httpShutdown)
+		if err := ps.HttpServer.Shutdown(ctx); err != nil {
+			utils.Log.Warnln("error shutting down http server:", err)
+		}
+	}()
+
+	drained := make(chan struct{})
+	go func() {
+		ps.inFlight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		utils.Log.Warnln("drain timed out before all in-flight work completed, proceeding with shutdown")
+	}
+	<-httpShutdown
+	ps.serverAvailability.http = false
+}
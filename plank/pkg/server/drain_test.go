@@ -0,0 +1,60 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDrainAndShutdownMarksDrainingImmediately(t *testing.T) {
+	ps := &platformServer{
+		HttpServer:         &http.Server{},
+		serverConfig:       &PlatformServerConfig{},
+		serverAvailability: &serverAvailability{},
+	}
+
+	ps.drainAndShutdown(time.Second)
+
+	if !ps.serverAvailability.draining {
+		t.Fatal("expected drainAndShutdown to mark the server as draining")
+	}
+}
+
+func TestDrainAndShutdownReturnsOnceInFlightWorkCompletes(t *testing.T) {
+	ps := &platformServer{
+		HttpServer:         &http.Server{},
+		serverConfig:       &PlatformServerConfig{},
+		serverAvailability: &serverAvailability{},
+	}
+
+	ps.inFlight.Add(1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		ps.inFlight.Done()
+	}()
+
+	start := time.Now()
+	ps.drainAndShutdown(time.Second)
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("drainAndShutdown took %v, expected it to return as soon as in-flight work finished", elapsed)
+	}
+}
+
+func TestDrainAndShutdownTimesOutWithStuckInFlightWork(t *testing.T) {
+	ps := &platformServer{
+		HttpServer:         &http.Server{},
+		serverConfig:       &PlatformServerConfig{},
+		serverAvailability: &serverAvailability{},
+	}
+
+	ps.inFlight.Add(1) // deliberately never Done, to exercise the timeout path
+
+	start := time.Now()
+	ps.drainAndShutdown(20 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("drainAndShutdown blocked for %v despite a 20ms timeout", elapsed)
+	}
+}
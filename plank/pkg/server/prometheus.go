@@ -12,12 +12,33 @@ import (
 	"github.com/vmware/transport-go/plank/pkg/middleware"
 )
 
-// enablePrometheus sets up /prometheus endpoint for metrics
+// enablePrometheus sets up the /prometheus endpoint, and registers the RED/latency
+// instrumentation middleware so every REST-bridge request and fabric channel is
+// observable per-service and per-endpoint rather than only via the default gatherer.
+//
+// httpMetrics is installed with router.Use rather than ps.middlewareManager.AddMiddleware: it
+// calls mux.CurrentRoute, which only resolves once the router itself has matched the request, so
+// it has to run from inside ps.router rather than in the chain wrapped around it from the
+// outside.
 func enablePrometheus(ps *platformServer) {
+	cfg := ps.serverConfig.MetricsConfig
+	gatherer := prometheus.Gatherer(prometheus.DefaultGatherer)
+	if cfg != nil && cfg.Registerer != nil {
+		if reg, ok := cfg.Registerer.(prometheus.Gatherer); ok {
+			gatherer = reg
+		}
+	}
+
+	ps.httpMetrics = middleware.NewPrometheusMetrics(cfg)
+	ps.fabricMetrics = middleware.NewFabricMetrics(cfg)
+
+	ps.routerMu.Lock()
+	defer ps.routerMu.Unlock()
 	ps.router.Path("/prometheus").Handler(
 		middleware.BasicSecurityHeaderMiddleware.Intercept(promhttp.HandlerFor(
-			prometheus.DefaultGatherer,
+			gatherer,
 			promhttp.HandlerOpts{
 				EnableOpenMetrics: true,
 			})))
+	ps.router.Use(ps.httpMetrics.Intercept)
 }
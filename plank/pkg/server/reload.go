@@ -0,0 +1,236 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hashicorp/consul/api"
+
+	"github.com/vmware/transport-go/plank/utils"
+)
+
+// restartRequiredFields lists the PlatformServerConfig fields ReloadConfig cannot safely apply
+// to a running instance, because changing them requires rebinding listeners or reconnecting
+// to the fabric broker.
+var restartRequiredFields = []string{"Host", "Port", "FabricConfig"}
+
+// ReloadConfig diffs cfg against the server's running configuration and applies whatever is
+// safely mutable without a restart - the StaticDir list, log levels, REST-bridge timeouts, the
+// Prometheus toggle (enabling only; once enabled it cannot be torn back out of the middleware
+// chain without a restart), and TLS certs (re-read from disk in place) - while logging a warning
+// when Host, Port or FabricConfig.FabricEndpoint differs; other FabricConfig changes are ignored
+// without a warning. StaticDir is only copied into the running config: static routes already
+// mounted on the router are not added, removed or remounted. If ConfigReloadConfig.EnvPrefix is set,
+// environment variables are re-read and applied onto cfg before the diff, so env-sourced
+// overrides take precedence over whatever is in the reloaded file/Consul value.
+func (ps *platformServer) ReloadConfig(cfg *PlatformServerConfig) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	current := ps.serverConfig
+
+	if current.ConfigReloadConfig != nil && current.ConfigReloadConfig.EnvPrefix != "" {
+		applyEnvOverrides(cfg, current.ConfigReloadConfig.EnvPrefix)
+	}
+
+	if cfg.Host != current.Host || cfg.Port != current.Port {
+		utils.Log.Warnln("host/port change requires a server restart, ignoring for live reload")
+	}
+	if cfg.FabricConfig != nil && current.FabricConfig != nil && cfg.FabricConfig.FabricEndpoint != current.FabricConfig.FabricEndpoint {
+		utils.Log.Warnln("fabric endpoint change requires a server restart, ignoring for live reload")
+	}
+
+	current.Debug = cfg.Debug
+	current.RestBridgeTimeoutInMinutes = cfg.RestBridgeTimeoutInMinutes
+	current.ShutdownTimeoutInMinutes = cfg.ShutdownTimeoutInMinutes
+	current.DrainDelayInSeconds = cfg.DrainDelayInSeconds
+	current.StaticDir = cfg.StaticDir
+	current.MetricsConfig = cfg.MetricsConfig
+
+	if cfg.EnablePrometheus && !current.EnablePrometheus {
+		enablePrometheus(ps)
+		current.EnablePrometheus = true
+	} else if !cfg.EnablePrometheus && current.EnablePrometheus {
+		utils.Log.Warnln("disabling prometheus requires a server restart, the /prometheus route and its middleware stay mounted")
+	}
+
+	if cfg.TLSCertConfig != nil && ps.HttpServer.TLSConfig != nil {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertConfig.CertFile, cfg.TLSCertConfig.KeyFile)
+		if err != nil {
+			return err
+		}
+		ps.HttpServer.TLSConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	utils.Log.Infoln("applied reloaded configuration")
+	return nil
+}
+
+// applyEnvOverrides scans os.Environ() for variables prefixed with prefix and applies the ones
+// that map onto a reloadable PlatformServerConfig field, so ConfigReloadConfig.EnvPrefix behaves
+// the same way on every reload trigger (SIGHUP, file watch, Consul) rather than being read once
+// at startup and then ignored.
+func applyEnvOverrides(cfg *PlatformServerConfig, prefix string) {
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		switch strings.TrimPrefix(name, prefix) {
+		case "HOST":
+			cfg.Host = value
+		case "PORT":
+			if p, err := strconv.Atoi(value); err == nil {
+				cfg.Port = p
+			}
+		case "DEBUG":
+			if b, err := strconv.ParseBool(value); err == nil {
+				cfg.Debug = b
+			}
+		case "ENABLE_PROMETHEUS":
+			if b, err := strconv.ParseBool(value); err == nil {
+				cfg.EnablePrometheus = b
+			}
+		case "REST_BRIDGE_TIMEOUT_IN_MINUTES":
+			if m, err := strconv.Atoi(value); err == nil {
+				cfg.RestBridgeTimeoutInMinutes = time.Duration(m)
+			}
+		case "SHUTDOWN_TIMEOUT_IN_MINUTES":
+			if m, err := strconv.Atoi(value); err == nil {
+				cfg.ShutdownTimeoutInMinutes = time.Duration(m)
+			}
+		case "DRAIN_DELAY_IN_SECONDS":
+			if s, err := strconv.Atoi(value); err == nil {
+				cfg.DrainDelayInSeconds = time.Duration(s)
+			}
+		}
+	}
+}
+
+// watchSIGHUP registers a SIGHUP handler that calls ps.ReloadConfig with the config file the
+// server was originally started with re-read from disk, matching the Traefik/Prometheus
+// convention of reloading live config on SIGHUP.
+func watchSIGHUP(ps *platformServer, configFilePath string) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	go func() {
+		for range sigChan {
+			if err := ps.reloadFromFile(configFilePath); err != nil {
+				utils.Log.Warnln("failed to reload config on SIGHUP:", err)
+			}
+		}
+	}()
+}
+
+// reloadFromFile reads and parses path as a PlatformServerConfig and applies it via ReloadConfig.
+func (ps *platformServer) reloadFromFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cfg PlatformServerConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+	return ps.ReloadConfig(&cfg)
+}
+
+// watchConfigFile fsnotify-watches configFilePath and calls reloadFromFile on every write.
+func watchConfigFile(ps *platformServer, configFilePath string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(configFilePath); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					if err := ps.reloadFromFile(configFilePath); err != nil {
+						utils.Log.Warnln("failed to reload config after file change:", err)
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				utils.Log.Warnln("config file watcher error:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// watchConsul watches the single Consul KV key named by ConsulKeyPrefix with blocking queries and
+// reloads the config, parsed as JSON from that key's value, whenever the query index changes.
+// Keys below that name are not read.
+func watchConsul(ps *platformServer, reloadCfg *ConfigReloadConfig) error {
+	client, err := api.NewClient(&api.Config{Address: reloadCfg.ConsulAddr})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		var lastIndex uint64
+		for {
+			pair, meta, err := client.KV().Get(reloadCfg.ConsulKeyPrefix, &api.QueryOptions{WaitIndex: lastIndex})
+			if err != nil {
+				utils.Log.Warnln("consul watch error:", err)
+				continue
+			}
+			if pair == nil || meta.LastIndex == lastIndex {
+				lastIndex = meta.LastIndex
+				continue
+			}
+			lastIndex = meta.LastIndex
+
+			var cfg PlatformServerConfig
+			if err := json.Unmarshal(pair.Value, &cfg); err != nil {
+				utils.Log.Warnln("failed to parse config from consul:", err)
+				continue
+			}
+			if err := ps.ReloadConfig(&cfg); err != nil {
+				utils.Log.Warnln("failed to apply config from consul:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// startConfigReloadWatchers wires up whichever hot-reload sources are configured in
+// reloadCfg, in addition to the SIGHUP handler that StartServer always installs.
+func startConfigReloadWatchers(ps *platformServer, configFilePath string) {
+	reloadCfg := ps.serverConfig.ConfigReloadConfig
+	if reloadCfg == nil {
+		return
+	}
+	if reloadCfg.WatchConfigFile && configFilePath != "" {
+		if err := watchConfigFile(ps, configFilePath); err != nil {
+			utils.Log.Warnln("failed to watch config file:", err)
+		}
+	}
+	if reloadCfg.ConsulAddr != "" && reloadCfg.ConsulKeyPrefix != "" {
+		if err := watchConsul(ps, reloadCfg); err != nil {
+			utils.Log.Warnln("failed to watch consul:", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyEnvOverridesAppliesMatchingPrefixedVars(t *testing.T) {
+	t.Setenv("PLANK_DEBUG", "true")
+	t.Setenv("PLANK_ENABLE_PROMETHEUS", "true")
+	t.Setenv("PLANK_SHUTDOWN_TIMEOUT_IN_MINUTES", "5")
+	t.Setenv("OTHER_DEBUG", "true") // different prefix, must be ignored
+
+	cfg := &PlatformServerConfig{}
+	applyEnvOverrides(cfg, "PLANK_")
+
+	if !cfg.Debug {
+		t.Error("expected PLANK_DEBUG=true to set cfg.Debug")
+	}
+	if !cfg.EnablePrometheus {
+		t.Error("expected PLANK_ENABLE_PROMETHEUS=true to set cfg.EnablePrometheus")
+	}
+	if cfg.ShutdownTimeoutInMinutes != time.Duration(5) {
+		t.Errorf("expected PLANK_SHUTDOWN_TIMEOUT_IN_MINUTES=5 to set cfg.ShutdownTimeoutInMinutes to 5, got %v", cfg.ShutdownTimeoutInMinutes)
+	}
+}
+
+func TestApplyEnvOverridesIgnoresUnparseableValues(t *testing.T) {
+	t.Setenv("PLANK_PORT", "not-a-number")
+
+	cfg := &PlatformServerConfig{Port: 8080}
+	applyEnvOverrides(cfg, "PLANK_")
+
+	if cfg.Port != 8080 {
+		t.Errorf("expected unparseable PLANK_PORT to leave cfg.Port untouched, got %d", cfg.Port)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/transport-go/plank/pkg/middleware"
+	"github.com/vmware/transport-go/plank/utils"
+	"github.com/vmware/transport-go/service"
+)
+
+// NewPlatformServer creates a PlatformServer around cfg, with its router, HTTP server and
+// middleware manager ready to receive routes and middleware. Call StartServer to begin serving.
+func NewPlatformServer(cfg *PlatformServerConfig) PlatformServer {
+	return &platformServer{
+		serverConfig:                 cfg,
+		serverAvailability:           &serverAvailability{},
+		router:                       mux.NewRouter(),
+		middlewareManager:            middleware.NewMiddlewareManager(),
+		HttpServer:                   &http.Server{Addr: fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)},
+		endpointHandlerMap:           make(map[string]http.HandlerFunc),
+		serviceChanToBridgeEndpoints: make(map[string][]string),
+		services:                     make(map[string]service.FabricService),
+		out:                          os.Stdout,
+	}
+}
+
+// StartServer starts the HTTP listener and blocks until syschan receives a signal, at which
+// point it calls StopServer.
+func (ps *platformServer) StartServer(syschan chan os.Signal) {
+	ps.SyscallChan = syschan
+
+	if ps.serverConfig.ACMEConfig != nil || ps.serverConfig.TLSCertConfig != nil {
+		if err := ps.CustomizeTLSConfig(&tls.Config{}); err != nil {
+			utils.Log.Errorln("failed to configure TLS:", err)
+		}
+	}
+
+	enableAccessLogging(ps)
+	enableHealthReady(ps)
+
+	if ps.serverConfig.EnablePrometheus {
+		enablePrometheus(ps)
+	}
+
+	watchSIGHUP(ps, ps.serverConfig.ConfigFilePath)
+	startConfigReloadWatchers(ps, ps.serverConfig.ConfigFilePath)
+
+	if ps.serverConfig.FabricConfig != nil && ps.serverConfig.FabricConfig.AMQPConfig != nil {
+		ps.lock.Lock()
+		for svcChannel := range ps.services {
+			if err := bridgeFabricChannelsToAMQP(ps, svcChannel, ps.serverConfig.FabricConfig.AMQPConfig); err != nil {
+				utils.Log.Errorln("failed to bridge channel", svcChannel, "to AMQP:", err)
+			}
+		}
+		ps.lock.Unlock()
+	}
+
+	ps.HttpServer.Handler = ps.trackInFlight(ps.middlewareManager.WrapHandler(ps.guardRouter()))
+	ps.serverAvailability.http = true
+
+	go func() {
+		var err error
+		if ps.HttpServer.TLSConfig != nil {
+			err = ps.HttpServer.ListenAndServeTLS("", "")
+		} else {
+			err = ps.HttpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			utils.Log.Errorln("http server stopped:", err)
+		}
+	}()
+
+	<-syschan
+	ps.StopServer()
+}
+
+// guardRouter wraps ps.router so every request read-locks routerMu, matching the write lock taken
+// by SetHttpChannelBridge, SetStaticRoute and enablePrometheus whenever they register a new route
+// or router-level middleware - including at runtime, via ReloadConfig - so those mutations never
+// race gorilla/mux's internal route slice against a request being matched concurrently.
+func (ps *platformServer) guardRouter() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ps.routerMu.RLock()
+		defer ps.routerMu.RUnlock()
+		ps.router.ServeHTTP(w, r)
+	})
+}
+
+// StopServer drains in-flight work and shuts down the HTTP server (see drainAndShutdown, which
+// owns both), then tears down whatever else was started in StartServer. The drain and the
+// teardown are each bounded by ShutdownTimeoutInMinutes, so the whole call can take up to the
+// drain delay plus twice that timeout.
+func (ps *platformServer) StopServer() {
+	timeout := ps.serverConfig.ShutdownTimeoutInMinutes * time.Minute
+	ps.drainAndShutdown(timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if ps.acmeChallengeServer != nil {
+		if err := ps.acmeChallengeServer.Shutdown(ctx); err != nil {
+			utils.Log.Warnln("error shutting down acme http-01 challenge listener:", err)
+		}
+	}
+
+	if ps.tracerProvider != nil {
+		if err := ps.tracerProvider.Shutdown(ctx); err != nil {
+			utils.Log.Warnln("error shutting down tracer provider:", err)
+		}
+	}
+}
+
+// CustomizeTLSConfig replaces the HTTP server's tls.Config, sourcing certificates from
+// ACMEConfig when set, falling back to the static cert/key pair in TLSCertConfig, and otherwise
+// installing tlsConfig as provided by the caller.
+func (ps *platformServer) CustomizeTLSConfig(tlsConfig *tls.Config) error {
+	if cfg := ps.serverConfig.ACMEConfig; cfg != nil {
+		acmeTLSConfig, err := configureACME(ps, cfg)
+		if err != nil {
+			return err
+		}
+		ps.HttpServer.TLSConfig = acmeTLSConfig
+		return nil
+	}
+	if cfg := ps.serverConfig.TLSCertConfig; cfg != nil {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		tlsConfig.InsecureSkipVerify = cfg.SkipCertificateValidation
+	}
+	ps.HttpServer.TLSConfig = tlsConfig
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vmware/transport-go/plank/pkg/middleware"
+	"github.com/vmware/transport-go/service"
+)
+
+// RegisterService records svc as the handler for svcChannel. svcChannel must not already have a
+// service registered on it.
+func (ps *platformServer) RegisterService(svc service.FabricService, svcChannel string) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, exists := ps.services[svcChannel]; exists {
+		return fmt.Errorf("a service is already registered on channel %q", svcChannel)
+	}
+	ps.services[svcChannel] = svc
+	return nil
+}
+
+// SetHttpChannelBridge mounts an HTTP route for bridgeConfig.Uri/Method on ps.router under a
+// name GetRestBridgeSubRoute can look up, and records it in endpointHandlerMap and
+// serviceChanToBridgeEndpoints. The mounted handler replies 502 when no service is registered on
+// bridgeConfig.ServiceChannel and 501 otherwise; requests are not forwarded to the service.
+func (ps *platformServer) SetHttpChannelBridge(bridgeConfig *service.RESTBridgeConfig) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	method := bridgeConfig.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	routeName := restBridgeRouteName(method, bridgeConfig.Uri)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		defer func() {
+			if ps.fabricMetrics != nil {
+				ps.fabricMetrics.ObserveRequestResponse(bridgeConfig.ServiceChannel, time.Since(start))
+			}
+		}()
+
+		ps.lock.Lock()
+		_, registered := ps.services[bridgeConfig.ServiceChannel]
+		ps.lock.Unlock()
+
+		if !registered {
+			http.Error(w, fmt.Sprintf("no service registered on channel %q", bridgeConfig.ServiceChannel), http.StatusBadGateway)
+			return
+		}
+		http.Error(w, "REST bridge dispatch is not yet implemented", http.StatusNotImplemented)
+	}
+
+	ps.endpointHandlerMap[routeName] = handler
+	ps.serviceChanToBridgeEndpoints[bridgeConfig.ServiceChannel] = append(
+		ps.serviceChanToBridgeEndpoints[bridgeConfig.ServiceChannel], routeName)
+
+	ps.routerMu.Lock()
+	defer ps.routerMu.Unlock()
+	ps.router.Path(bridgeConfig.Uri).Methods(method).Name(routeName).HandlerFunc(handler)
+}
+
+// SetStaticRoute mounts fullpath as a static file server under prefix.
+func (ps *platformServer) SetStaticRoute(prefix, fullpath string) {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	ps.routerMu.Lock()
+	defer ps.routerMu.Unlock()
+	ps.router.PathPrefix(prefix).Handler(http.StripPrefix(prefix, http.FileServer(http.Dir(fullpath))))
+}
+
+// GetRestBridgeSubRoute returns the *mux.Route previously mounted by SetHttpChannelBridge for
+// uri/method, or an error if no such bridge was ever set up.
+func (ps *platformServer) GetRestBridgeSubRoute(uri, method string) (*mux.Route, error) {
+	routeName := restBridgeRouteName(method, uri)
+	route := ps.router.Get(routeName)
+	if route == nil {
+		return nil, fmt.Errorf("no REST bridge route registered for %s %s", method, uri)
+	}
+	return route, nil
+}
+
+// GetMiddlewareManager returns the MiddlewareManager used to build the HTTP handler chain
+// installed on ps.HttpServer in StartServer.
+func (ps *platformServer) GetMiddlewareManager() middleware.MiddlewareManager {
+	return ps.middlewareManager
+}
+
+// restBridgeRouteName builds the mux route name a REST bridge endpoint is registered and
+// looked up under, so SetHttpChannelBridge and GetRestBridgeSubRoute agree on the same key.
+func restBridgeRouteName(method, uri string) string {
+	return method + " " + uri
+}
@@ -85,4 +85,28 @@ var PlatformServerFlagConstants = map[string]map[string]string{
 		"FlagName":    "prometheus",
 		"Description": "Enable Prometheus for basic runtime metrics",
 	},
+	"AcmeEmail": {
+		"FlagName":    "acme-email",
+		"Description": "Contact email registered with the ACME (Let's Encrypt) account",
+	},
+	"AcmeDomains": {
+		"FlagName":    "acme-domains",
+		"Description": "Comma-separated list of domains to request ACME certificates for",
+	},
+	"AcmeStorage": {
+		"FlagName":    "acme-storage",
+		"Description": "Path to the directory used to cache ACME certificates and account keys, one file per entry",
+	},
+	"AcmeCAServer": {
+		"FlagName":    "acme-caserver",
+		"Description": "ACME CA directory URL (default: Let's Encrypt production endpoint)",
+	},
+	"AcmeOnDemand": {
+		"FlagName":    "acme-on-demand",
+		"Description": "Request ACME certificates on demand for whatever SNI hostname is seen, instead of pre-issuing for --acme-domains",
+	},
+	"DrainDelay": {
+		"FlagName":    "drain-delay",
+		"Description": "Seconds /health/ready reports not-ready before in-flight work starts draining, giving load balancers time to depool the instance",
+	},
 }
\ No newline at end of file
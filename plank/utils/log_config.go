@@ -0,0 +1,14 @@
+// Copyright 2019-2021 VMware, Inc.
+// SPDX-License-Identifier: BSD-2-Clause
+
+package utils
+
+import "github.com/vmware/transport-go/plank/pkg/middleware"
+
+// LogConfig holds the output destinations and formatting options for Plank's log streams.
+type LogConfig struct {
+	OutputLog       string                     `json:"output_log"`        // path to the platform log output, "" writes to stdout
+	AccessLog       string                     `json:"access_log"`        // path to the HTTP access log output, "" writes to stdout
+	ErrorLog        string                     `json:"error_log"`         // path to the HTTP error log output, "" writes to stdout
+	AccessLogFormat middleware.AccessLogFormat `json:"access_log_format"` // structured format ("json" or "logfmt") used for access log records, defaults to logfmt
+}